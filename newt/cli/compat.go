@@ -0,0 +1,121 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/repo"
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newt/viper"
+)
+
+// NewtUsage prints err (if any) followed by cmd's usage, then exits.  It
+// mirrors the error-reporting convention used by newt's other subcommands.
+func NewtUsage(cmd *cobra.Command, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+	}
+	if cmd != nil {
+		cmd.Help()
+	}
+	os.Exit(1)
+}
+
+// parseResolvedDeps parses a list of "depname@version" args -- the
+// dependency versions actually resolved for the current project, e.g. as
+// read out of project state -- into the map FormatCompat needs to check
+// repo.repo_compatibility against concrete versions.
+func parseResolvedDeps(args []string) (map[string]newtutil.Version, error) {
+	deps := make(map[string]newtutil.Version, len(args))
+
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "@", 2)
+		if len(parts) != 2 {
+			return nil, util.FmtNewtError(
+				"Invalid dependency specifier \"%s\"; expected "+
+					"\"depname@version\"", arg)
+		}
+
+		ver, err := newtutil.ParseVersion(parts[1])
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"Invalid version in dependency specifier \"%s\": %s",
+				arg, err.Error())
+		}
+
+		deps[parts[0]] = ver
+	}
+
+	return deps, nil
+}
+
+// compatRunCmd implements `newt compat`: it loads the current directory's
+// repository.yml and prints the resolved newt-tool and repo-to-repo
+// compatibility matrix for it, so a user can diagnose which combination is
+// tripping a warn or error result.  Any "depname@version" arguments name
+// the dependency versions actually resolved for this project; each is
+// checked against repo.repo_compatibility via the same path dependency
+// resolution uses.
+func compatRunCmd(cmd *cobra.Command, args []string) {
+	v := viper.New()
+	v.SetConfigName("repository")
+	v.AddConfigPath(".")
+	if err := v.ReadInConfig(); err != nil {
+		NewtUsage(cmd, util.FmtNewtError(
+			"Failed to read repository.yml: %s", err.Error()))
+	}
+
+	selfVerStr := v.GetString("repo.version")
+	selfVer, err := newtutil.ParseVersion(selfVerStr)
+	if err != nil {
+		NewtUsage(cmd, util.FmtNewtError(
+			"repository.yml has invalid repo.version \"%s\"", selfVerStr))
+	}
+
+	resolvedDeps, err := parseResolvedDeps(args)
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	matrix, err := repo.FormatCompat(v, selfVer, newtutil.NewtVersion,
+		resolvedDeps)
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	fmt.Print(matrix)
+}
+
+// AddCompatCommands adds the `compat` subcommand to the root newt command.
+func AddCompatCommands(cmd *cobra.Command) {
+	compatCmd := &cobra.Command{
+		Use:   "compat [depname@version ...]",
+		Short: "Print the resolved newt/repo compatibility matrix for the current project",
+		Run:   compatRunCmd,
+	}
+
+	cmd.AddCommand(compatCmd)
+}
@@ -0,0 +1,40 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+)
+
+// NewtCmd constructs the root `newt` command that every subcommand package
+// registers itself onto.
+func NewtCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "newt",
+		Short:   "Newt is a tool to help you compose and build your own OS",
+		Version: newtutil.NewtVersion.String(),
+	}
+
+	AddCompatCommands(cmd)
+
+	return cmd
+}
@@ -0,0 +1,113 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package newtutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// NewtVersion is the version of the currently running newt binary.  It is
+// set once at release-tagging time; development builds keep the "dev"
+// prerelease tag below.
+var NewtVersion = Version{Major: 1, Minor: 9, Revision: 0, Prerelease: "dev"}
+
+// Version represents a newt / repo version number of the form
+// "Major.Minor.Revision", with an optional dash-separated prerelease tag
+// (e.g. "1.0.0-rc1").
+type Version struct {
+	Major      int64
+	Minor      int64
+	Revision   int64
+	Prerelease string
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Revision)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// ParseVersion parses a version string of the form "X.Y.Z" or
+// "X.Y.Z-prerelease" into a Version.  Trailing components may be omitted
+// (e.g. "1" or "1.2").
+func ParseVersion(verStr string) (Version, error) {
+	v := Version{}
+
+	parts := strings.SplitN(verStr, "-", 2)
+	v.Prerelease = ""
+	if len(parts) == 2 {
+		v.Prerelease = parts[1]
+	}
+
+	fields := strings.Split(parts[0], ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return v, util.FmtNewtError("Invalid version string: %s", verStr)
+	}
+
+	nums := make([]int64, 3)
+	for i, f := range fields {
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return v, util.FmtNewtError("Invalid version string: %s", verStr)
+		}
+		nums[i] = n
+	}
+
+	v.Major = nums[0]
+	v.Minor = nums[1]
+	v.Revision = nums[2]
+
+	return v, nil
+}
+
+// VerCmp compares two versions.  It returns a negative number if a < b,
+// zero if a == b, and a positive number if a > b.  A prerelease version is
+// considered older than the same version without a prerelease tag (e.g.
+// 1.0.0-rc1 < 1.0.0), matching semver precedence rules; two differing
+// prerelease tags are compared lexically.
+func VerCmp(a Version, b Version) int {
+	if a.Major != b.Major {
+		return int(a.Major - b.Major)
+	}
+	if a.Minor != b.Minor {
+		return int(a.Minor - b.Minor)
+	}
+	if a.Revision != b.Revision {
+		return int(a.Revision - b.Revision)
+	}
+
+	if a.Prerelease == b.Prerelease {
+		return 0
+	}
+	if a.Prerelease == "" {
+		return 1
+	}
+	if b.Prerelease == "" {
+		return -1
+	}
+
+	return strings.Compare(a.Prerelease, b.Prerelease)
+}
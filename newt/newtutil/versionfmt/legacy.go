@@ -0,0 +1,67 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package versionfmt
+
+import (
+	"math"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+)
+
+// legacyFormat is the original newt three-int version scheme
+// ("Major.Minor.Revision", with an optional prerelease tag).  It is the
+// default format for `repo.newt_compatibility` tables that don't declare
+// one, preserving behavior for repos written before versionfmt existed.
+type legacyFormat struct{}
+
+func init() {
+	Register(legacyFormat{})
+}
+
+func (legacyFormat) Name() string {
+	return "newt-legacy"
+}
+
+func (legacyFormat) Parse(verStr string) (Version, error) {
+	v, err := newtutil.ParseVersion(verStr)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (legacyFormat) Valid(verStr string) bool {
+	_, err := newtutil.ParseVersion(verStr)
+	return err == nil
+}
+
+func (f legacyFormat) Compare(a Version, b Version) int {
+	av := a.(*newtutil.Version)
+	bv := b.(*newtutil.Version)
+	return newtutil.VerCmp(*av, *bv)
+}
+
+func (legacyFormat) Infinity() Version {
+	return &newtutil.Version{
+		Major:    math.MaxInt64,
+		Minor:    math.MaxInt64,
+		Revision: math.MaxInt64,
+	}
+}
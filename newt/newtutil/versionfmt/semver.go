@@ -0,0 +1,165 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package versionfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// semverVer is a parsed Semantic Versioning 2.0.0 version
+// ("major.minor.patch-prerelease+build").
+type semverVer struct {
+	major, minor, patch int64
+	prerelease          string
+	build               string
+}
+
+func (v *semverVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.prerelease != "" {
+		s += "-" + v.prerelease
+	}
+	if v.build != "" {
+		s += "+" + v.build
+	}
+	return s
+}
+
+type semverFormat struct{}
+
+func init() {
+	Register(semverFormat{})
+}
+
+func (semverFormat) Name() string {
+	return "semver"
+}
+
+func (semverFormat) Parse(verStr string) (Version, error) {
+	v := &semverVer{}
+
+	rest := verStr
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		v.build = rest[i+1:]
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		v.prerelease = rest[i+1:]
+		rest = rest[:i]
+	}
+
+	fields := strings.Split(rest, ".")
+	if len(fields) != 3 {
+		return nil, util.FmtNewtError("Invalid semver version string: %s",
+			verStr)
+	}
+
+	nums := make([]int64, 3)
+	for i, f := range fields {
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, util.FmtNewtError("Invalid semver version string: %s",
+				verStr)
+		}
+		nums[i] = n
+	}
+
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+func (f semverFormat) Valid(verStr string) bool {
+	_, err := f.Parse(verStr)
+	return err == nil
+}
+
+func (semverFormat) Compare(a Version, b Version) int {
+	av := a.(*semverVer)
+	bv := b.(*semverVer)
+
+	if av.major != bv.major {
+		return int(av.major - bv.major)
+	}
+	if av.minor != bv.minor {
+		return int(av.minor - bv.minor)
+	}
+	if av.patch != bv.patch {
+		return int(av.patch - bv.patch)
+	}
+
+	// Build metadata never affects precedence.  A prerelease version has
+	// lower precedence than the associated normal version.
+	if av.prerelease == bv.prerelease {
+		return 0
+	}
+	if av.prerelease == "" {
+		return 1
+	}
+	if bv.prerelease == "" {
+		return -1
+	}
+
+	return comparePrerelease(av.prerelease, bv.prerelease)
+}
+
+// comparePrerelease implements semver's dot-separated identifier comparison:
+// numeric identifiers compare numerically and are always lower precedence
+// than alphanumeric identifiers; a larger set of identifiers has higher
+// precedence than a shorter one that is otherwise identical.
+func comparePrerelease(a, b string) int {
+	aIds := strings.Split(a, ".")
+	bIds := strings.Split(b, ".")
+
+	for i := 0; i < len(aIds) && i < len(bIds); i++ {
+		ai, aErr := strconv.ParseInt(aIds[i], 10, 64)
+		bi, bErr := strconv.ParseInt(bIds[i], 10, 64)
+
+		if aErr == nil && bErr == nil {
+			if ai != bi {
+				return int(ai - bi)
+			}
+			continue
+		}
+		if aErr == nil {
+			return -1
+		}
+		if bErr == nil {
+			return 1
+		}
+
+		if cmp := strings.Compare(aIds[i], bIds[i]); cmp != 0 {
+			return cmp
+		}
+	}
+
+	return len(aIds) - len(bIds)
+}
+
+func (semverFormat) Infinity() Version {
+	return &semverVer{
+		major: 1<<63 - 1,
+		minor: 1<<63 - 1,
+		patch: 1<<63 - 1,
+	}
+}
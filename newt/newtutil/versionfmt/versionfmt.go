@@ -0,0 +1,88 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package versionfmt decouples newt's version-compatibility machinery (see
+// the repo package) from any single version numbering scheme.  Different
+// repos version their newt-compatibility tables differently: newt itself
+// uses a three-int scheme, but a repo may declare that its table uses
+// another scheme instead.  Each scheme is a Format, registered under a
+// name that a repository.yml can select via `repo.newt_compatibility`'s
+// `format` key.  The registered formats are "newt-legacy" (the default)
+// and "semver"; any other name is rejected by MustGet.
+package versionfmt
+
+import (
+	"mynewt.apache.org/newt/util"
+)
+
+// Version is an opaque, format-specific parsed version.  Values are only
+// ever compared against other Versions produced by the same Format.
+type Version interface {
+	String() string
+}
+
+// Format parses and compares versions in a particular numbering scheme.
+type Format interface {
+	// Name is the string used to select this format in a
+	// `repo.newt_compatibility` table's `format` key.
+	Name() string
+
+	// Parse converts a version string into this format's Version type.
+	Parse(verStr string) (Version, error)
+
+	// Compare returns a negative number if a < b, zero if a == b, and a
+	// positive number if a > b.  Behavior is undefined if a or b did not
+	// originate from this Format.
+	Compare(a Version, b Version) int
+
+	// Valid reports whether verStr can be parsed by this format.
+	Valid(verStr string) bool
+
+	// Infinity returns a sentinel Version known to compare greater than
+	// any version this format can parse.  It replaces the old
+	// math.MaxInt64-based sentinel used when a compatibility range has no
+	// upper bound.
+	Infinity() Version
+}
+
+var formats = map[string]Format{}
+
+// Register adds a Format to the registry under its Name().  Called from
+// each backend's package init().
+func Register(f Format) {
+	formats[f.Name()] = f
+}
+
+// Get looks up a registered Format by name.
+func Get(name string) (Format, bool) {
+	f, ok := formats[name]
+	return f, ok
+}
+
+// MustGet looks up a registered Format by name, returning an error suitable
+// for surfacing to the user if it is not registered.
+func MustGet(name string) (Format, error) {
+	f, ok := Get(name)
+	if !ok {
+		return nil, util.FmtNewtError(
+			"Unknown newt compatibility version format: %s", name)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,300 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newt/viper"
+)
+
+// updateCheckTimeout bounds how long the advisor's remote fetch is allowed
+// to block; a slow or hanging remote must never stall a newt invocation.
+const updateCheckTimeout = 5 * time.Second
+
+// updateCheckInterval is how often the background advisor is allowed to
+// fetch a repo's repository.yml to look for a newer newt release.  This
+// keeps every newt invocation from hitting the network.
+const updateCheckInterval = 24 * time.Hour
+
+// updateStatusRelPath is the cache file's path, relative to the project
+// directory.
+const updateStatusRelPath = ".newt/update_status.yml"
+
+// newtDownloadURL is included in the upgrade notice printed by the
+// advisor.
+const newtDownloadURL = "http://mynewt.apache.org/download/"
+
+// UpdateStatus is the on-disk record of the advisor's last run, persisted
+// at <project>/.newt/update_status.yml.
+type UpdateStatus struct {
+	LastCheck  time.Time `yaml:"last_check"`
+	LastMaxVer string    `yaml:"last_max_ver"`
+}
+
+func readUpdateStatus(projectDir string) (UpdateStatus, error) {
+	path := filepath.Join(projectDir, updateStatusRelPath)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return UpdateStatus{}, nil
+		}
+		return UpdateStatus{}, util.FmtNewtError(
+			"Failed to read update status file \"%s\": %s", path, err.Error())
+	}
+
+	st := UpdateStatus{}
+	if err := yaml.Unmarshal(data, &st); err != nil {
+		return UpdateStatus{}, util.FmtNewtError(
+			"Failed to parse update status file \"%s\": %s", path, err.Error())
+	}
+
+	return st, nil
+}
+
+func writeUpdateStatus(projectDir string, st UpdateStatus) error {
+	path := filepath.Join(projectDir, updateStatusRelPath)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return util.FmtNewtError(
+			"Failed to create update status directory \"%s\": %s",
+			filepath.Dir(path), err.Error())
+	}
+
+	data, err := yaml.Marshal(&st)
+	if err != nil {
+		return util.FmtNewtError("Failed to marshal update status: %s",
+			err.Error())
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return util.FmtNewtError(
+			"Failed to write update status file \"%s\": %s", path, err.Error())
+	}
+
+	return nil
+}
+
+// LatestRecommended scans every per-repo-version table in the map and
+// reports the highest version known to satisfy an explicit "good"
+// constraint (bare-version entries are not considered), the severity that
+// currentVer itself evaluates to against those tables, and an upgrade URL
+// to display alongside a notice.  It is used by the background advisor to
+// decide whether newer newt release is worth announcing; it does not
+// replace CheckNewtVer, which remains the build-blocking check.
+//
+// Different repo versions may declare their newt_compatibility table in
+// different versionfmt formats (that's the point of a `format` key), so
+// the per-table "good" target can't be compared against the running best
+// via either table's Format.Compare -- the two Versions may not even be
+// the same concrete type, and the comparison would panic.  Candidates are
+// normalized through their Version.String() and newtutil.ParseVersion/VerCmp
+// to compare safely across formats.  A candidate whose string form
+// ParseVersion rejects (e.g. a semver version carrying "+build" metadata)
+// is skipped.
+func (mp *NewtCompatMap) LatestRecommended(currentVer newtutil.Version) (
+	*newtutil.Version, NewtCompatCode, string) {
+
+	var best *newtutil.Version
+	worst := NEWT_COMPAT_GOOD
+
+	for _, tbl := range mp.verTableMap {
+		if tbl.format == nil {
+			continue
+		}
+
+		cur, err := tbl.format.Parse(currentVer.String())
+		if err != nil {
+			continue
+		}
+
+		if code, _ := tbl.CheckNewtVer(cur); code > worst {
+			worst = code
+		}
+
+		tgt := tbl.highestGoodVer()
+		if tgt == nil {
+			continue
+		}
+
+		tgtVer, err := newtutil.ParseVersion(tgt.String())
+		if err != nil {
+			continue
+		}
+
+		if best == nil || newtutil.VerCmp(tgtVer, *best) > 0 {
+			best = &tgtVer
+		}
+	}
+
+	return best, worst, newtDownloadURL
+}
+
+// UpdateCheckDisabled reports whether the update-check advisor has been
+// turned off, either via `project.update_check: false` in project.yml or
+// via the NEWT_NO_UPDATE_CHECK environment variable.
+func UpdateCheckDisabled(v *viper.Viper) bool {
+	if os.Getenv("NEWT_NO_UPDATE_CHECK") != "" {
+		return true
+	}
+
+	return v != nil && v.IsSet("project.update_check") &&
+		!v.GetBool("project.update_check")
+}
+
+// FetchRemoteCompatMap retrieves repository.yml from a repo's remote (e.g.
+// the raw-content URL of its default branch) and parses its
+// repo.newt_compatibility table.  It is the fetch implementation
+// StartUpdateCheck uses via LoadRepoCompat.
+func FetchRemoteCompatMap(remoteRepoURL string) (*NewtCompatMap, error) {
+	client := http.Client{Timeout: updateCheckTimeout}
+
+	url := strings.TrimRight(remoteRepoURL, "/") + "/repository.yml"
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"Failed to fetch \"%s\": %s", url, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, util.FmtNewtError(
+			"Failed to fetch \"%s\": HTTP %d", url, resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"Failed to read \"%s\": %s", url, err.Error())
+	}
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, util.FmtNewtError(
+			"Failed to parse \"%s\": %s", url, err.Error())
+	}
+
+	return readNcMap(v)
+}
+
+// LoadRepoCompat reads repoDir's repository.yml into a NewtCompatMap and a
+// RepoCompatMap and, unless disabled via project.yml or
+// NEWT_NO_UPDATE_CHECK, starts the background update-check advisor against
+// remoteRepoURL (the repo's configured remote, e.g. its raw-content URL).
+// This is the call site NewRepo uses to load a repo's compatibility tables
+// once per configured repo, after parsing that repo's repository.yml.
+func LoadRepoCompat(repoDir string, projectDir string, remoteRepoURL string,
+	currentNewtVer newtutil.Version) (*NewtCompatMap, *RepoCompatMap, error) {
+
+	v := viper.New()
+	v.SetConfigName("repository")
+	v.AddConfigPath(repoDir)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, util.FmtNewtError(
+			"Failed to read \"%s/repository.yml\": %s", repoDir, err.Error())
+	}
+
+	nc, err := readNcMap(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rc, err := readRepoCompatMap(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if remoteRepoURL != "" {
+		pv := viper.New()
+		pv.SetConfigName("project")
+		pv.AddConfigPath(projectDir)
+		if err := pv.ReadInConfig(); err != nil {
+			pv = nil
+		}
+
+		StartUpdateCheck(projectDir, currentNewtVer, UpdateCheckDisabled(pv),
+			func() (*NewtCompatMap, error) {
+				return FetchRemoteCompatMap(remoteRepoURL)
+			})
+	}
+
+	return nc, rc, nil
+}
+
+// StartUpdateCheck launches a background goroutine that looks for a newer
+// "good" newt version and, rate-limited to once per updateCheckInterval per
+// project, prints a one-time notice the first time a strictly newer
+// version is observed.  fetch should retrieve and parse the remote repo's
+// current repository.yml into a NewtCompatMap; LoadRepoCompat supplies
+// FetchRemoteCompatMap for this.
+func StartUpdateCheck(projectDir string, currentVer newtutil.Version,
+	disabled bool, fetch func() (*NewtCompatMap, error)) {
+
+	if disabled || fetch == nil {
+		return
+	}
+
+	go func() {
+		st, err := readUpdateStatus(projectDir)
+		if err != nil {
+			return
+		}
+
+		if !st.LastCheck.IsZero() &&
+			time.Since(st.LastCheck) < updateCheckInterval {
+			return
+		}
+
+		mp, err := fetch()
+		if err != nil {
+			return
+		}
+
+		best, _, url := mp.LatestRecommended(currentVer)
+		st.LastCheck = time.Now()
+
+		if best != nil && best.String() != st.LastMaxVer {
+			if newtutil.VerCmp(*best, currentVer) > 0 {
+				msg := fmt.Sprintf("newt %s available (you are on %s)",
+					best.String(), currentVer.String())
+				if url != "" {
+					msg += ": " + url
+				}
+				util.StatusMessage(util.VERBOSITY_QUIET, "%s\n", msg)
+			}
+			st.LastMaxVer = best.String()
+		}
+
+		writeUpdateStatus(projectDir, st)
+	}()
+}
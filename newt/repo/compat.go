@@ -21,16 +21,22 @@ package repo
 
 import (
 	"fmt"
-	"math"
 	"sort"
+	"strings"
 
 	"github.com/spf13/cast"
 
 	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/newtutil/versionfmt"
 	"mynewt.apache.org/newt/util"
 	"mynewt.apache.org/newt/viper"
 )
 
+// defaultNcFormat is the version format assumed for a `repo.newt_compatibility`
+// table that doesn't declare a `format` entry.  It preserves the original
+// three-int behavior for repos written before versionfmt existed.
+const defaultNcFormat = "newt-legacy"
+
 type NewtCompatCode int
 
 const (
@@ -45,16 +51,115 @@ var NewtCompatCodeNames = map[NewtCompatCode]string{
 	NEWT_COMPAT_ERROR: "error",
 }
 
+// ncOp is the comparison operator of a single constraint clause, e.g. the
+// ">=" in ">=1.0.0 <1.5.0".
+type ncOp int
+
+const (
+	ncOpGte ncOp = iota
+	ncOpGt
+	ncOpLte
+	ncOpLt
+	ncOpEq
+)
+
+// ncOpTable lists the recognized operator tokens, longest first so that
+// e.g. ">=" is matched before ">".
+var ncOpTable = []struct {
+	str string
+	op  ncOp
+}{
+	{">=", ncOpGte},
+	{"<=", ncOpLte},
+	{"==", ncOpEq},
+	{">", ncOpGt},
+	{"<", ncOpLt},
+}
+
+// NewtCompatClause is a single "<op><version>" term, e.g. ">=1.0.0".
+type NewtCompatClause struct {
+	op  ncOp
+	ver versionfmt.Version
+}
+
+func (cl NewtCompatClause) matches(vf versionfmt.Format, ver versionfmt.Version) bool {
+	cmp := vf.Compare(ver, cl.ver)
+	switch cl.op {
+	case ncOpGte:
+		return cmp >= 0
+	case ncOpGt:
+		return cmp > 0
+	case ncOpLte:
+		return cmp <= 0
+	case ncOpLt:
+		return cmp < 0
+	case ncOpEq:
+		return cmp == 0
+	default:
+		return false
+	}
+}
+
+// NewtCompatConstraint is a set of clauses that are AND-ed together, e.g.
+// ">=1.0.0 <1.5.0" means "1.0.0 <= ver < 1.5.0".  A table entry may specify
+// several constraints (OR-ed, separated by "||"); see parseNcConstraints.
+type NewtCompatConstraint struct {
+	clauses []NewtCompatClause
+}
+
+func (c NewtCompatConstraint) matches(vf versionfmt.Format, ver versionfmt.Version) bool {
+	for _, cl := range c.clauses {
+		if !cl.matches(vf, ver) {
+			return false
+		}
+	}
+	return true
+}
+
 type NewtCompatEntry struct {
-	code       NewtCompatCode
-	minNewtVer newtutil.Version
+	code NewtCompatCode
+
+	// bare is true for entries specified as a plain version (the legacy
+	// "1.0.0: good" form).  Such entries are treated as an implicit
+	// [minNewtVer, <next entry>) range, determined by sort order.
+	bare       bool
+	minNewtVer versionfmt.Version
+
+	// constraints holds the parsed range expression for non-bare entries.
+	// Entries in this slice are OR-ed together.
+	constraints []NewtCompatConstraint
 }
 
-type NewtCompatTable struct {
-	// Sorted in ascending order by newt version number.
+func (e *NewtCompatEntry) matchesAny(vf versionfmt.Format, ver versionfmt.Version) bool {
+	for _, c := range e.constraints {
+		if c.matches(vf, ver) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompatTable holds the good/warn/error ranges for one "subject" version
+// axis.  It was originally specific to newt-tool compatibility, but the
+// matchIdx/idxRangesWithCode/minMaxTgtVers sweep and the constraint
+// evaluation in CheckNewtVer don't care what the subject represents --
+// NewtCompatMap keys tables by repo version to check the running newt
+// tool, and RepoCompatMap keys tables the same way to check a resolved
+// dependency repo version instead.
+type CompatTable struct {
+	// format is the version scheme used to parse and compare every
+	// version in this table.
+	format versionfmt.Format
+
+	// Every parsed entry, bare and constraint alike, in no particular
+	// order; legacyEntries() returns the bare ones sorted by version.
 	entries []NewtCompatEntry
 }
 
+// NewtCompatTable is CompatTable under its original, newt-version-specific
+// name; kept so existing callers don't need to change.
+type NewtCompatTable = CompatTable
+
 type NewtCompatMap struct {
 	verTableMap map[newtutil.Version]NewtCompatTable
 }
@@ -80,13 +185,82 @@ func newtCompatCodeFromString(codeStr string) (NewtCompatCode, error) {
 		util.FmtNewtError("Invalid newt compatibility code: %s", codeStr)
 }
 
-func parseNcEntry(verStr string, codeStr string) (NewtCompatEntry, error) {
+// isConstraintExpr reports whether verStr is a range expression (e.g.
+// ">=1.0.0 <1.5.0") rather than a bare version (e.g. "1.0.0").
+func isConstraintExpr(verStr string) bool {
+	for _, e := range ncOpTable {
+		if strings.Contains(verStr, e.str) {
+			return true
+		}
+	}
+	return strings.Contains(verStr, "||")
+}
+
+func parseNcClause(vf versionfmt.Format, tok string) (NewtCompatClause, error) {
+	for _, e := range ncOpTable {
+		if strings.HasPrefix(tok, e.str) {
+			ver, err := vf.Parse(tok[len(e.str):])
+			if err != nil {
+				return NewtCompatClause{}, err
+			}
+			return NewtCompatClause{op: e.op, ver: ver}, nil
+		}
+	}
+
+	ver, err := vf.Parse(tok)
+	if err != nil {
+		return NewtCompatClause{}, err
+	}
+	return NewtCompatClause{op: ncOpEq, ver: ver}, nil
+}
+
+// parseNcConstraints parses a range expression into a set of OR-ed
+// constraints, each itself a set of AND-ed clauses, e.g.
+// ">=1.0.0 <1.5.0 || >=2.0.0" is two constraints.
+func parseNcConstraints(vf versionfmt.Format, expr string) (
+	[]NewtCompatConstraint, error) {
+
+	var constraints []NewtCompatConstraint
+
+	for _, orPart := range strings.Split(expr, "||") {
+		fields := strings.Fields(orPart)
+		if len(fields) == 0 {
+			return nil, util.FmtNewtError(
+				"Invalid newt compatibility constraint: %s", expr)
+		}
+
+		c := NewtCompatConstraint{}
+		for _, f := range fields {
+			clause, err := parseNcClause(vf, f)
+			if err != nil {
+				return nil, err
+			}
+			c.clauses = append(c.clauses, clause)
+		}
+
+		constraints = append(constraints, c)
+	}
+
+	return constraints, nil
+}
+
+func parseNcEntry(vf versionfmt.Format, verStr string, codeStr string) (
+	NewtCompatEntry, error) {
+
 	entry := NewtCompatEntry{}
 	var err error
 
-	entry.minNewtVer, err = newtutil.ParseVersion(verStr)
-	if err != nil {
-		return entry, err
+	if isConstraintExpr(verStr) {
+		entry.constraints, err = parseNcConstraints(vf, verStr)
+		if err != nil {
+			return entry, err
+		}
+	} else {
+		entry.bare = true
+		entry.minNewtVer, err = vf.Parse(verStr)
+		if err != nil {
+			return entry, err
+		}
 	}
 
 	entry.code, err = newtCompatCodeFromString(codeStr)
@@ -97,11 +271,30 @@ func parseNcEntry(verStr string, codeStr string) (NewtCompatEntry, error) {
 	return entry, nil
 }
 
+// ncFormatName extracts and removes the optional `format` key from a
+// compatibility sub-table, returning the format name to use (defaulting to
+// defaultNcFormat if unspecified).
+func ncFormatName(strMap map[string]string) string {
+	name, ok := strMap["format"]
+	if !ok {
+		return defaultNcFormat
+	}
+
+	delete(strMap, "format")
+	return name
+}
+
 func parseNcTable(strMap map[string]string) (NewtCompatTable, error) {
 	tbl := NewtCompatTable{}
 
+	vf, err := versionfmt.MustGet(ncFormatName(strMap))
+	if err != nil {
+		return tbl, err
+	}
+	tbl.format = vf
+
 	for c, v := range strMap {
-		entry, err := parseNcEntry(c, v)
+		entry, err := parseNcEntry(vf, c, v)
 		if err != nil {
 			return tbl, err
 		}
@@ -109,8 +302,6 @@ func parseNcTable(strMap map[string]string) (NewtCompatTable, error) {
 		tbl.entries = append(tbl.entries, entry)
 	}
 
-	sortEntries(tbl.entries)
-
 	return tbl, nil
 }
 
@@ -142,13 +333,28 @@ func readNcMap(v *viper.Viper) (*NewtCompatMap, error) {
 	return mp, nil
 }
 
-func (tbl *NewtCompatTable) matchIdx(newtVer newtutil.Version) int {
+// legacyEntries returns the bare-version entries, sorted in ascending order
+// by version.  This is the table that the original index-sweep logic
+// (matchIdx / idxRangesWithCode / minMaxTgtVers) operates on.
+func (tbl *NewtCompatTable) legacyEntries() []NewtCompatEntry {
+	var bare []NewtCompatEntry
+	for _, e := range tbl.entries {
+		if e.bare {
+			bare = append(bare, e)
+		}
+	}
+
+	sortEntries(tbl.format, bare)
+	return bare
+}
+
+func (tbl *NewtCompatTable) matchIdx(newtVer versionfmt.Version) int {
 	// Iterate the table backwards.  The first entry whose version is less than
 	// or equal to the specified version is the match.
 	for i := 0; i < len(tbl.entries); i++ {
 		idx := len(tbl.entries) - i - 1
 		entry := &tbl.entries[idx]
-		cmp := newtutil.VerCmp(entry.minNewtVer, newtVer)
+		cmp := tbl.format.Compare(entry.minNewtVer, newtVer)
 		if cmp <= 0 {
 			return idx
 		}
@@ -169,7 +375,7 @@ func (tbl *NewtCompatTable) newIdxRange(i int, j int) []int {
 	e1 := tbl.entries[i]
 	e2 := tbl.entries[j]
 
-	if newtutil.VerCmp(e1.minNewtVer, e2.minNewtVer) < 0 {
+	if tbl.format.Compare(e1.minNewtVer, e2.minNewtVer) < 0 {
 		return []int{i, j}
 	} else {
 		return []int{j, i}
@@ -200,15 +406,15 @@ func (tbl *NewtCompatTable) idxRangesWithCode(c NewtCompatCode) [][]int {
 }
 
 func (tbl *NewtCompatTable) minMaxTgtVers(goodRange []int) (
-	newtutil.Version, newtutil.Version, newtutil.Version) {
+	versionfmt.Version, versionfmt.Version, versionfmt.Version) {
 
 	minVer := tbl.entries[goodRange[0]].minNewtVer
 
-	var maxVer newtutil.Version
+	var maxVer versionfmt.Version
 	if goodRange[1] < len(tbl.entries) {
 		maxVer = tbl.entries[goodRange[1]].minNewtVer
 	} else {
-		maxVer = newtutil.Version{math.MaxInt64, math.MaxInt64, math.MaxInt64}
+		maxVer = tbl.format.Infinity()
 	}
 
 	targetVer := tbl.entries[goodRange[1]-1].minNewtVer
@@ -216,34 +422,37 @@ func (tbl *NewtCompatTable) minMaxTgtVers(goodRange []int) (
 	return minVer, maxVer, targetVer
 }
 
-// @return NewtCompatCode       The severity of the newt incompatibility
-//         string               The warning or error message to display in case
-//                                  of incompatibility.
-func (tbl *NewtCompatTable) CheckNewtVer(
-	newtVer newtutil.Version) (NewtCompatCode, string) {
+// checkLegacy evaluates the bare-version entries using the original
+// sorted-index sweep.  It is the fallback used for versions that no
+// explicit constraint entry matches.  Callers must only invoke this when
+// the table has at least one bare entry; see CheckNewtVer.
+func (tbl *NewtCompatTable) checkLegacy(newtVer versionfmt.Version) (
+	NewtCompatCode, string) {
+
+	lt := &NewtCompatTable{format: tbl.format, entries: tbl.legacyEntries()}
 
 	var code NewtCompatCode
-	idx := tbl.matchIdx(newtVer)
+	idx := lt.matchIdx(newtVer)
 	if idx == -1 {
 		// This version of newt is older than every entry in the table.
 		code = NEWT_COMPAT_ERROR
 	} else {
-		code = tbl.entries[idx].code
+		code = lt.entries[idx].code
 		if code == NEWT_COMPAT_GOOD {
 			return NEWT_COMPAT_GOOD, ""
 		}
 	}
 
-	goodRanges := tbl.idxRangesWithCode(NEWT_COMPAT_GOOD)
+	goodRanges := lt.idxRangesWithCode(NEWT_COMPAT_GOOD)
 	for i := 0; i < len(goodRanges); i++ {
-		minVer, maxVer, tgtVer := tbl.minMaxTgtVers(goodRanges[i])
+		minVer, maxVer, tgtVer := lt.minMaxTgtVers(goodRanges[i])
 
-		if newtutil.VerCmp(newtVer, minVer) < 0 {
+		if tbl.format.Compare(newtVer, minVer) < 0 {
 			return code, fmt.Sprintf("Please upgrade your newt tool to "+
 				"version %s", tgtVer.String())
 		}
 
-		if newtutil.VerCmp(newtVer, maxVer) >= 0 {
+		if tbl.format.Compare(newtVer, maxVer) >= 0 {
 			return code, "Please upgrade your repos with \"newt upgrade\""
 		}
 	}
@@ -251,7 +460,136 @@ func (tbl *NewtCompatTable) CheckNewtVer(
 	return code, ""
 }
 
+// constraintBounds reports the effective lower and upper bounds a
+// constraint's clauses place on a match, e.g. ">=1.0.0 <1.5.0" has
+// min=1.0.0 (inclusive) and max=1.5.0 (exclusive).  hasMin/hasMax are
+// false when the constraint leaves that side open (e.g. ">=2.0.0" has no
+// upper bound at all).
+func constraintBounds(c NewtCompatConstraint) (
+	min versionfmt.Version, hasMin bool,
+	max versionfmt.Version, hasMax bool) {
+
+	for _, cl := range c.clauses {
+		switch cl.op {
+		case ncOpGte, ncOpGt, ncOpEq:
+			if !hasMin {
+				min, hasMin = cl.ver, true
+			}
+		}
+		switch cl.op {
+		case ncOpLte, ncOpLt, ncOpEq:
+			if !hasMax {
+				max, hasMax = cl.ver, true
+			}
+		}
+	}
+
+	return min, hasMin, max, hasMax
+}
+
+// highestVerInConstraint returns the highest of c's declared bound versions
+// that c itself accepts: its upper bound if inclusive (<=, ==), else its
+// lower bound if inclusive (>=, ==).  E.g. ">=1.0.0 <1.5.0" yields 1.0.0,
+// since 1.5.0 lies outside the range, and ">=2.0.0" yields 2.0.0.  It
+// returns nil when c accepts neither bound (e.g. ">1.0.0 <1.5.0").
+func highestVerInConstraint(vf versionfmt.Format,
+	c NewtCompatConstraint) versionfmt.Version {
+
+	min, hasMin, max, hasMax := constraintBounds(c)
+	if hasMax && c.matches(vf, max) {
+		return max
+	}
+	if hasMin && c.matches(vf, min) {
+		return min
+	}
+	return nil
+}
+
+// highestGoodVer returns the highest version known to satisfy a "good"
+// constraint in the table, for use in upgrade recommendations.  It returns
+// nil if the table has no explicit "good" constraints.
+func (tbl *NewtCompatTable) highestGoodVer() versionfmt.Version {
+	var best versionfmt.Version
+
+	for _, e := range tbl.entries {
+		if e.code != NEWT_COMPAT_GOOD {
+			continue
+		}
+		for _, c := range e.constraints {
+			cand := highestVerInConstraint(tbl.format, c)
+			if cand == nil {
+				continue
+			}
+
+			if best == nil || tbl.format.Compare(cand, best) > 0 {
+				best = cand
+			}
+		}
+	}
+
+	return best
+}
+
+// upgradeMsg picks the upgrade-tool-vs-upgrade-repos message for newtVer
+// relative to the table's "good" constraints: if newtVer is older than the
+// highest version any good constraint covers, the newt tool is behind and
+// should be upgraded to that version; otherwise the tool is already ahead
+// of what the repos declare support for, and it's the repos that need
+// upgrading.  highestGoodVer() picks that target deterministically, unlike
+// a per-entry scan, which would depend on parseNcTable's unspecified map
+// iteration order whenever more than one disjoint good range exists.
+func (tbl *NewtCompatTable) upgradeMsg(newtVer versionfmt.Version) string {
+	if tgt := tbl.highestGoodVer(); tgt != nil {
+		if tbl.format.Compare(newtVer, tgt) < 0 {
+			return fmt.Sprintf("Please upgrade your newt tool to version %s",
+				tgt.String())
+		}
+	}
+
+	return "Please upgrade your repos with \"newt upgrade\""
+}
+
+// @return NewtCompatCode       The severity of the newt incompatibility
+//         string               The warning or error message to display in case
+//                                  of incompatibility.
+func (tbl *NewtCompatTable) CheckNewtVer(
+	newtVer versionfmt.Version) (NewtCompatCode, string) {
+
+	matched := false
+	code := NEWT_COMPAT_GOOD
+
+	for _, e := range tbl.entries {
+		if e.bare || !e.matchesAny(tbl.format, newtVer) {
+			continue
+		}
+
+		matched = true
+		if e.code > code {
+			code = e.code
+		}
+	}
+
+	if matched {
+		if code == NEWT_COMPAT_GOOD {
+			return NEWT_COMPAT_GOOD, ""
+		}
+		return code, tbl.upgradeMsg(newtVer)
+	}
+
+	if len(tbl.legacyEntries()) > 0 {
+		return tbl.checkLegacy(newtVer)
+	}
+
+	// Every entry in this table is an explicit constraint and none of
+	// them matched, so newtVer falls outside every declared range (below,
+	// above, or in a gap between them).  The original sweep treated
+	// "older than every entry" as an error; any unmatched version is
+	// reported as an error here rather than silently as GOOD.
+	return NEWT_COMPAT_ERROR, tbl.upgradeMsg(newtVer)
+}
+
 type entrySorter struct {
+	format  versionfmt.Format
 	entries []NewtCompatEntry
 }
 
@@ -265,7 +603,7 @@ func (s entrySorter) Less(i, j int) bool {
 	e1 := s.entries[i]
 	e2 := s.entries[j]
 
-	cmp := newtutil.VerCmp(e1.minNewtVer, e2.minNewtVer)
+	cmp := s.format.Compare(e1.minNewtVer, e2.minNewtVer)
 	if cmp < 0 {
 		return true
 	} else if cmp > 0 {
@@ -275,10 +613,11 @@ func (s entrySorter) Less(i, j int) bool {
 	return false
 }
 
-func sortEntries(entries []NewtCompatEntry) {
+func sortEntries(vf versionfmt.Format, entries []NewtCompatEntry) {
 	sorter := entrySorter{
+		format:  vf,
 		entries: entries,
 	}
 
 	sort.Sort(sorter)
-}
\ No newline at end of file
+}
@@ -0,0 +1,73 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package repo
+
+import (
+	"mynewt.apache.org/newt/newt/newtutil"
+)
+
+// Repo represents a single repo that the current project depends on, as
+// loaded from its repository.yml: its name, its own version, and the
+// newt-tool/repo-to-repo compatibility tables it declares.
+type Repo struct {
+	Name string
+	Vers newtutil.Version
+
+	// NewtCompat is r's repo.newt_compatibility table, checked against the
+	// running newt tool's own version.
+	NewtCompat *NewtCompatMap
+
+	// RepoCompat is r's repo.repo_compatibility table, checked against the
+	// versions of the repos r itself depends on.
+	RepoCompat *RepoCompatMap
+}
+
+// NewRepo loads repoDir's repository.yml for the repo named name, current
+// at version selfVer, and starts its background newt-version advisor
+// (unless disabled) against remoteURL, the repo's configured remote.  An
+// empty remoteURL disables the advisor for this repo.  This is the call
+// site a project load is expected to invoke once per configured repo.
+func NewRepo(repoDir string, projectDir string, name string,
+	selfVer newtutil.Version, remoteURL string) (*Repo, error) {
+
+	nc, rc, err := LoadRepoCompat(repoDir, projectDir, remoteURL,
+		newtutil.NewtVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repo{
+		Name:       name,
+		Vers:       selfVer,
+		NewtCompat: nc,
+		RepoCompat: rc,
+	}, nil
+}
+
+// ResolveDependency validates depVer, the version the dependency resolver
+// picked for the dependency repo depName, against r's repo_compatibility
+// table before that version is installed.  Per ValidateRepoCompat, an
+// incompatible pair blocks the install; a merely-discouraged one only
+// prints a warning.  This is meant to be called once per dependency, after
+// the resolver settles on a concrete version for it and before that
+// version is installed.
+func (r *Repo) ResolveDependency(depName string, depVer newtutil.Version) error {
+	return ValidateRepoCompat(r.RepoCompat, r.Name, r.Vers, depName, depVer)
+}
@@ -0,0 +1,226 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package repo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cast"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newt/viper"
+)
+
+// RepoCompatMap is the repo-to-repo counterpart of NewtCompatMap: instead
+// of checking the running newt tool against a repo's declared
+// `repo.newt_compatibility`, it checks a resolved dependency repo's
+// version against the dependent's declared `repo.repo_compatibility`.
+//
+// It is keyed first by dependency repo name, then -- like NewtCompatMap --
+// by the dependent repo's own version, since a dependent may tighten or
+// relax its compatibility requirements for a dependency across its own
+// releases.
+type RepoCompatMap struct {
+	repoTableMap map[string]map[newtutil.Version]CompatTable
+}
+
+func newRepoCompatMap() *RepoCompatMap {
+	return &RepoCompatMap{
+		repoTableMap: map[string]map[newtutil.Version]CompatTable{},
+	}
+}
+
+func readRepoCompatMap(v *viper.Viper) (*RepoCompatMap, error) {
+	mp := newRepoCompatMap()
+
+	rcMap := v.GetStringMap("repo.repo_compatibility")
+	for depName, verMapItf := range rcMap {
+		verMap := cast.ToStringMap(verMapItf)
+
+		depTbl := map[newtutil.Version]CompatTable{}
+		for k, v := range verMap {
+			selfVer, err := newtutil.ParseVersion(k)
+			if err != nil {
+				return nil, util.FmtNewtError("Repo compatibility table for "+
+					"\"%s\" contains invalid repo version \"%s\"", depName, k)
+			}
+
+			if _, ok := depTbl[selfVer]; ok {
+				return nil, util.FmtNewtError("Repo compatibility table for "+
+					"\"%s\" contains duplicate version specifier: %s",
+					depName, selfVer.String())
+			}
+
+			strMap := cast.ToStringMapString(v)
+			tbl, err := parseNcTable(strMap)
+			if err != nil {
+				return nil, err
+			}
+
+			depTbl[selfVer] = tbl
+		}
+
+		mp.repoTableMap[depName] = depTbl
+	}
+
+	return mp, nil
+}
+
+// CheckRepoVer validates depVer, the version of the dependency repo
+// depName that was actually resolved, against the table the dependent
+// (currently at selfVer) declares for it.  It returns NEWT_COMPAT_GOOD
+// with no message if the map has no opinion -- either because depName
+// isn't listed at all, or because selfVer isn't a key in its table.
+func (mp *RepoCompatMap) CheckRepoVer(depName string,
+	selfVer newtutil.Version, depVer newtutil.Version) (NewtCompatCode, string) {
+
+	depTbl, ok := mp.repoTableMap[depName]
+	if !ok {
+		return NEWT_COMPAT_GOOD, ""
+	}
+
+	tbl, ok := depTbl[selfVer]
+	if !ok {
+		return NEWT_COMPAT_GOOD, ""
+	}
+
+	ver, err := tbl.format.Parse(depVer.String())
+	if err != nil {
+		return NEWT_COMPAT_GOOD, ""
+	}
+
+	return tbl.CheckNewtVer(ver)
+}
+
+// ValidateRepoCompat checks a resolved (selfName@selfVer, depName@depVer)
+// dependency pair against selfName's RepoCompatMap, if any, and returns an
+// error for a NEWT_COMPAT_ERROR result.  A NEWT_COMPAT_WARN result is
+// printed but does not block the build, matching the severity semantics
+// CheckNewtVer already uses for newt-tool compatibility.  compat may be
+// nil, in which case every pair passes.
+//
+// Repo.ResolveDependency calls this once per dependency, after the
+// dependency resolver settles on a concrete version for it and before that
+// version is installed.
+func ValidateRepoCompat(compat *RepoCompatMap, selfName string,
+	selfVer newtutil.Version, depName string, depVer newtutil.Version) error {
+
+	if compat == nil {
+		return nil
+	}
+
+	code, msg := compat.CheckRepoVer(depName, selfVer, depVer)
+	switch code {
+	case NEWT_COMPAT_ERROR:
+		return util.FmtNewtError(
+			"Repo \"%s\" version %s is incompatible with \"%s\" version "+
+				"%s: %s", depName, depVer.String(), selfName,
+			selfVer.String(), msg)
+
+	case NEWT_COMPAT_WARN:
+		util.StatusMessage(util.VERBOSITY_QUIET,
+			"Warning: repo \"%s\" version %s and \"%s\" version %s: %s\n",
+			depName, depVer.String(), selfName, selfVer.String(), msg)
+	}
+
+	return nil
+}
+
+// FormatCompatMatrix renders the resolved newt-tool and repo-to-repo
+// compatibility matrix for a repo currently at selfVer, for the `newt
+// compat` subcommand.  newtVer is the currently running newt tool's
+// version.  resolvedDeps maps each dependency repo name to the version
+// actually resolved for it; each one is run through the same
+// ValidateRepoCompat call dependency resolution uses, so the printed
+// matrix reflects real resolved versions rather than a dump of the raw
+// table.
+func FormatCompatMatrix(selfName string, selfVer newtutil.Version,
+	newtVer newtutil.Version, nc *NewtCompatMap, rc *RepoCompatMap,
+	resolvedDeps map[string]newtutil.Version) string {
+
+	out := fmt.Sprintf("Compatibility matrix for \"%s\" %s:\n",
+		selfName, selfVer.String())
+
+	if nc != nil {
+		if tbl, ok := nc.verTableMap[selfVer]; ok {
+			ver, err := tbl.format.Parse(newtVer.String())
+			if err == nil {
+				code, msg := tbl.CheckNewtVer(ver)
+				out += fmt.Sprintf("  newt tool %s: %s", newtVer.String(),
+					newtCompatCodeToString(code))
+				if msg != "" {
+					out += " (" + msg + ")"
+				}
+				out += "\n"
+			}
+		}
+	}
+
+	if rc != nil {
+		depNames := make([]string, 0, len(resolvedDeps))
+		for depName := range resolvedDeps {
+			depNames = append(depNames, depName)
+		}
+		sort.Strings(depNames)
+
+		for _, depName := range depNames {
+			depVer := resolvedDeps[depName]
+
+			code, msg := rc.CheckRepoVer(depName, selfVer, depVer)
+			out += fmt.Sprintf("  %s %s: %s", depName, depVer.String(),
+				newtCompatCodeToString(code))
+			if msg != "" {
+				out += " (" + msg + ")"
+			}
+			out += "\n"
+
+			if err := ValidateRepoCompat(rc, selfName, selfVer, depName,
+				depVer); err != nil {
+				out += fmt.Sprintf("    %s\n", err.Error())
+			}
+		}
+	}
+
+	return out
+}
+
+// FormatCompat reads repo.newt_compatibility and repo.repo_compatibility
+// out of v and renders the matrix for selfVer via FormatCompatMatrix.  It
+// is the entry point used by the `newt compat` CLI subcommand.
+func FormatCompat(v *viper.Viper, selfVer newtutil.Version,
+	newtVer newtutil.Version, resolvedDeps map[string]newtutil.Version) (
+	string, error) {
+
+	nc, err := readNcMap(v)
+	if err != nil {
+		return "", err
+	}
+
+	rc, err := readRepoCompatMap(v)
+	if err != nil {
+		return "", err
+	}
+
+	selfName := v.GetString("repo.name")
+	return FormatCompatMatrix(selfName, selfVer, newtVer, nc, rc,
+		resolvedDeps), nil
+}